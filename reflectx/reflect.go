@@ -7,6 +7,7 @@
 package reflectx
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"runtime"
@@ -15,13 +16,26 @@ import (
 )
 
 type fieldInfo struct {
-	Index    []int
-	Path     string
-	Field    reflect.StructField
-	Zero     reflect.Value
-	Name     string
-	Options  map[string]string
-	Embedded bool
+	Index      []int
+	Path       string
+	Field      reflect.StructField
+	Zero       reflect.Value
+	Name       string
+	Options    map[string]string
+	Embedded   bool
+	OmitEmpty  bool
+	ReadOnly   bool
+	PrimaryKey bool
+	Immutable  bool
+}
+
+// builtinOptions are the well-known tag options that are always recognized,
+// regardless of what's been registered with Mapper.RegisterOption.
+var builtinOptions = map[string]bool{
+	"omitempty": true,
+	"readonly":  true,
+	"pk":        true,
+	"immutable": true,
 }
 
 type fields []fieldInfo
@@ -69,11 +83,82 @@ func (f fields) FieldMap() map[string]fieldInfo {
 // behaves like most marshallers, optionally obeying a field tag for name
 // mapping and a function to provide a basic mapping of fields to names.
 type Mapper struct {
-	cache      map[reflect.Type]fields
-	tagName    string
-	tagMapFunc func(string) string
-	mapFunc    func(string) string
-	mutex      sync.Mutex
+	cache           map[reflect.Type]fields
+	tagName         string
+	tagMapFunc      func(string) string
+	mapFunc         func(string) string
+	converters      []typeConverter
+	plans           map[reflect.Type]map[string]*TraversalPlan
+	strict          bool
+	conflictPolicy  ConflictPolicy
+	conflicts       map[reflect.Type]map[string][]FieldInfo
+	maxDepth        int
+	errOnDepthLimit bool
+	options         map[string]func(string) error
+	mutex           sync.Mutex
+}
+
+// defaultMaxDepth is the BFS depth a Mapper descends to when MaxDepth hasn't
+// been set via WithMaxDepth.
+const defaultMaxDepth = 10
+
+// Option configures optional Mapper behavior. Apply one or more with
+// SetOptions, typically right after construction.
+type Option func(*Mapper)
+
+// WithStrictFieldTraversal makes FieldByName and FieldsByName stop at nil
+// embedded pointers and nil maps instead of allocating into them.
+// FieldsByNameErr never allocates, with or without this option. Use this for read-only workloads -- such as
+// scanning into a partially-populated result type -- where mutating the
+// destination as a side effect of traversal is unwanted.
+func WithStrictFieldTraversal() Option {
+	return func(m *Mapper) {
+		m.mutex.Lock()
+		m.strict = true
+		m.mutex.Unlock()
+	}
+}
+
+// WithMaxDepth bounds how many levels of nested/embedded structs TypeMap
+// will descend into, guarding against runaway memory use on deeply nested
+// generated types. It defaults to defaultMaxDepth. It also doubles as the
+// mechanism that stops a self-referential type (e.g. a tree node embedding
+// itself through a pointer) from sending the BFS into an infinite loop: a
+// type that reappears on its own traversal path is never descended into
+// again, regardless of depth.
+func WithMaxDepth(n int) Option {
+	return func(m *Mapper) {
+		m.mutex.Lock()
+		m.maxDepth = n
+		m.mutex.Unlock()
+	}
+}
+
+// WithDepthLimitError makes TypeMapErr return an error wrapping a
+// *DepthLimitError when
+// MaxDepth or the recursion guard stops a type from being fully mapped,
+// instead of silently using the truncated mapping.
+func WithDepthLimitError() Option {
+	return func(m *Mapper) {
+		m.mutex.Lock()
+		m.errOnDepthLimit = true
+		m.mutex.Unlock()
+	}
+}
+
+// SetOptions applies opts to m.
+func (m *Mapper) SetOptions(opts ...Option) {
+	for _, opt := range opts {
+		opt(m)
+	}
+}
+
+// typeConverter holds a user-registered conversion function along with the
+// from/to types it was registered for.
+type typeConverter struct {
+	from reflect.Type
+	to   reflect.Type
+	fn   func(reflect.Value) (reflect.Value, error)
 }
 
 // NewMapper returns a new mapper which optionally obeys the field tag given
@@ -109,16 +194,215 @@ func NewMapperFunc(tagName string, f func(string) string) *Mapper {
 }
 
 // TypeMap returns a mapping of field strings to int slices representing
-// the traversal down the struct to reach the field.
+// the traversal down the struct to reach the field. Panics wherever
+// TypeMapErr would return an error (for instance when the Mapper's
+// ConflictPolicy rejects t); use TypeMapErr to handle those cases without a
+// panic.
 func (m *Mapper) TypeMap(t reflect.Type) fields {
+	mapping, err := m.TypeMapErr(t)
+	if err != nil {
+		panic(err)
+	}
+	return mapping
+}
+
+// TypeMapErr is the error-returning counterpart to TypeMap. It returns an
+// error when two or more fields -- typically from different embedded
+// structs -- map to the same name and the Mapper's ConflictPolicy doesn't
+// resolve the ambiguity (ConflictError always errors; ConflictShallowestWins
+// errors only when the competing fields are at the same embed depth). It
+// also returns an error for a tag option rejected under RegisterOption, and,
+// with WithDepthLimitError, when the depth limit or recursion guard fires.
+func (m *Mapper) TypeMapErr(t reflect.Type) (fields, error) {
 	m.mutex.Lock()
 	mapping, ok := m.cache[t]
-	if !ok {
-		mapping = getMapping(t, m.tagName, m.mapFunc, m.tagMapFunc)
-		m.cache[t] = mapping
+	policy := m.conflictPolicy
+	maxDepth := m.maxDepth
+	errOnDepthLimit := m.errOnDepthLimit
+	// Copy rather than alias m.options: getMapping reads it without holding
+	// m.mutex, and RegisterOption can be called concurrently from another
+	// goroutine.
+	options := make(map[string]func(string) error, len(m.options))
+	for name, validator := range m.options {
+		options[name] = validator
 	}
 	m.mutex.Unlock()
-	return mapping
+	if ok {
+		return mapping, nil
+	}
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxDepth
+	}
+
+	raw, depthErr, err := getMapping(t, m.tagName, m.mapFunc, m.tagMapFunc, maxDepth, options)
+	if err != nil {
+		return nil, err
+	}
+	if depthErr != nil && errOnDepthLimit {
+		return nil, fmt.Errorf("reflectx: %w", depthErr)
+	}
+
+	resolved, conflicts, err := resolveConflicts(raw, policy)
+	if err != nil {
+		return nil, fmt.Errorf("reflectx: %s: %w", t, err)
+	}
+
+	m.mutex.Lock()
+	m.cache[t] = resolved
+	if len(conflicts) > 0 {
+		if m.conflicts == nil {
+			m.conflicts = make(map[reflect.Type]map[string][]FieldInfo)
+		}
+		m.conflicts[t] = conflicts
+	}
+	m.mutex.Unlock()
+
+	return resolved, nil
+}
+
+// SetConflictPolicy sets the policy used to resolve multiple fields that map
+// to the same name, most commonly arising from two embedded structs that
+// each expose a field of the same name. The default, ConflictFirstWins,
+// matches the Mapper's historical behavior of keeping whichever field was
+// discovered first in BFS order.
+func (m *Mapper) SetConflictPolicy(policy ConflictPolicy) {
+	m.mutex.Lock()
+	m.conflictPolicy = policy
+	m.mutex.Unlock()
+}
+
+// Conflicts returns the set of ambiguous names found the last time t was
+// type-mapped, each mapped to the fieldInfo entries that competed for it.
+// It returns nil until TypeMap or TypeMapErr has been called for t, and nil
+// if t had no conflicts. Conflicts are recorded only when t maps without
+// error: for a type the ConflictPolicy rejects, it also returns nil.
+func (m *Mapper) Conflicts(t reflect.Type) map[string][]FieldInfo {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.conflicts[t]
+}
+
+// FieldInfo is an exported alias for the Mapper's internal field metadata,
+// exposed so that a ConflictCustom resolver can inspect the fields
+// competing for a name.
+type FieldInfo = fieldInfo
+
+// ConflictPolicy determines how TypeMap resolves multiple fields that map
+// to the same name. The zero value is ConflictFirstWins.
+type ConflictPolicy struct {
+	kind     conflictKind
+	resolver func([]FieldInfo) (FieldInfo, error)
+}
+
+type conflictKind int
+
+const (
+	conflictFirstWins conflictKind = iota
+	conflictShallowestWins
+	conflictError
+	conflictCustom
+)
+
+var (
+	// ConflictFirstWins keeps whichever field was discovered first in BFS
+	// order. This is the default and matches the Mapper's historical
+	// behavior.
+	ConflictFirstWins = ConflictPolicy{kind: conflictFirstWins}
+
+	// ConflictShallowestWins prefers the field with the smallest embed
+	// depth, mirroring encoding/json's field-selection rules. Fields tied
+	// at the same depth are reported as an error.
+	ConflictShallowestWins = ConflictPolicy{kind: conflictShallowestWins}
+
+	// ConflictError rejects any type with an ambiguous field name.
+	ConflictError = ConflictPolicy{kind: conflictError}
+)
+
+// ConflictCustom returns a ConflictPolicy that defers to fn to pick a winner
+// among the fields competing for a name.
+func ConflictCustom(fn func([]FieldInfo) (FieldInfo, error)) ConflictPolicy {
+	return ConflictPolicy{kind: conflictCustom, resolver: fn}
+}
+
+// resolveConflicts applies policy to raw, returning the deduplicated field
+// list in raw's original order, along with the set of names that had more
+// than one competing field.
+func resolveConflicts(raw fields, policy ConflictPolicy) (fields, map[string][]FieldInfo, error) {
+	byPath := map[string][]FieldInfo{}
+	order := []string{}
+	for _, fi := range raw {
+		if fi.Path == "" {
+			continue
+		}
+		if _, ok := byPath[fi.Path]; !ok {
+			order = append(order, fi.Path)
+		}
+		byPath[fi.Path] = append(byPath[fi.Path], fi)
+	}
+
+	conflicts := map[string][]FieldInfo{}
+	resolved := map[string]FieldInfo{}
+
+	for _, path := range order {
+		group := byPath[path]
+		if len(group) == 1 {
+			resolved[path] = group[0]
+			continue
+		}
+		conflicts[path] = group
+
+		switch policy.kind {
+		case conflictShallowestWins:
+			winner, tie := shallowestFieldInfo(group)
+			if tie {
+				return nil, nil, fmt.Errorf("ambiguous field %q: multiple fields at the same embed depth", path)
+			}
+			resolved[path] = winner
+		case conflictError:
+			return nil, nil, fmt.Errorf("ambiguous field %q: %d fields map to this name", path, len(group))
+		case conflictCustom:
+			fi, err := policy.resolver(group)
+			if err != nil {
+				return nil, nil, fmt.Errorf("resolving conflict for %q: %w", path, err)
+			}
+			resolved[path] = fi
+		default: // conflictFirstWins
+			resolved[path] = group[0]
+		}
+	}
+
+	out := make(fields, 0, len(raw))
+	seen := map[string]bool{}
+	for _, fi := range raw {
+		if fi.Path == "" {
+			out = append(out, fi)
+			continue
+		}
+		if seen[fi.Path] {
+			continue
+		}
+		seen[fi.Path] = true
+		out = append(out, resolved[fi.Path])
+	}
+	return out, conflicts, nil
+}
+
+// shallowestFieldInfo returns the field with the smallest embed depth (as
+// measured by its traversal index length) and whether two or more fields
+// are tied for shallowest.
+func shallowestFieldInfo(group []FieldInfo) (FieldInfo, bool) {
+	best := group[0]
+	tie := false
+	for _, fi := range group[1:] {
+		switch {
+		case len(fi.Index) < len(best.Index):
+			best = fi
+			tie = false
+		case len(fi.Index) == len(best.Index):
+			tie = true
+		}
+	}
+	return best, tie
 }
 
 // FieldMap returns the mapper's mapping of field names to reflect values.  Panics
@@ -147,9 +431,27 @@ func (m *Mapper) FieldByName(v reflect.Value, name string) reflect.Value {
 	if !ok {
 		return v
 	}
+	m.mutex.Lock()
+	strict := m.strict
+	m.mutex.Unlock()
+	if strict {
+		return fieldByIndexesStrict(v, fi.Index)
+	}
 	return FieldByIndexes(v, fi.Index)
 }
 
+// fieldByIndexesStrict is what FieldByName and FieldsByName traverse with
+// under WithStrictFieldTraversal: unlike FieldByIndexesReadOnly, it doesn't
+// panic on a nil embedded pointer, instead returning a zero reflect.Value,
+// the same result FieldsByName gives for an unknown name.
+func fieldByIndexesStrict(v reflect.Value, indexes []int) reflect.Value {
+	fv, err := FieldByIndexesErr(v, indexes)
+	if err != nil {
+		return reflect.Value{}
+	}
+	return fv
+}
+
 // FieldsByName returns a slice of values corresponding to the slice of names
 // for the value.  Panics if v's Kind is not Struct or v is not Indirectable
 // to a struct Kind.  Returns zero Value for each name not found.
@@ -158,11 +460,17 @@ func (m *Mapper) FieldsByName(v reflect.Value, names []string) []reflect.Value {
 	mustBe(v, reflect.Struct)
 
 	tm := m.TypeMap(v.Type())
+	m.mutex.Lock()
+	strict := m.strict
+	m.mutex.Unlock()
+
 	vals := make([]reflect.Value, 0, len(names))
 	for _, name := range names {
 		fi, ok := tm.GetByPath(name)
 		if !ok {
 			vals = append(vals, *new(reflect.Value))
+		} else if strict {
+			vals = append(vals, fieldByIndexesStrict(v, fi.Index))
 		} else {
 			vals = append(vals, FieldByIndexes(v, fi.Index))
 		}
@@ -170,6 +478,32 @@ func (m *Mapper) FieldsByName(v reflect.Value, names []string) []reflect.Value {
 	return vals
 }
 
+// FieldsByNameErr is the error-returning counterpart to FieldsByName. Rather
+// than silently traversing into (and, unless WithStrictFieldTraversal is
+// set, allocating) a nil embedded pointer, it stops and returns an error
+// wrapping a *NilEmbeddedError for the first name whose traversal hits one. Panics if
+// v's Kind is not Struct or v is not Indirectable to a struct Kind.
+func (m *Mapper) FieldsByNameErr(v reflect.Value, names []string) ([]reflect.Value, error) {
+	v = reflect.Indirect(v)
+	mustBe(v, reflect.Struct)
+
+	tm := m.TypeMap(v.Type())
+	vals := make([]reflect.Value, 0, len(names))
+	for _, name := range names {
+		fi, ok := tm.GetByPath(name)
+		if !ok {
+			vals = append(vals, *new(reflect.Value))
+			continue
+		}
+		fv, err := FieldByIndexesErr(v, fi.Index)
+		if err != nil {
+			return nil, fmt.Errorf("reflectx: field %q: %w", name, err)
+		}
+		vals = append(vals, fv)
+	}
+	return vals, nil
+}
+
 // TraversalsByName returns a slice of int slices which represent the struct
 // traversals for each mapped name.  Panics if t is not a struct or Indirectable
 // to a struct.  Returns empty int slice for each name not found.
@@ -190,6 +524,291 @@ func (m *Mapper) TraversalsByName(t reflect.Type, names []string) [][]int {
 	return r
 }
 
+// WritableTraversalsByName is TraversalsByName, but returns an empty []int
+// for any name whose field is marked ReadOnly (a "readonly" tag option),
+// the same way TraversalsByName already does for names that aren't found.
+// This lets a caller building INSERT/UPDATE column lists skip read-only
+// columns, such as a "db:\"id,pk,readonly\"" primary key, without having to
+// re-parse tags itself.
+func (m *Mapper) WritableTraversalsByName(t reflect.Type, names []string) [][]int {
+	t = Deref(t)
+	mustBe(t, reflect.Struct)
+	tm := m.TypeMap(t)
+
+	r := make([][]int, 0, len(names))
+	for _, name := range names {
+		fi, ok := tm.GetByPath(name)
+		if !ok || fi.ReadOnly {
+			r = append(r, []int{})
+		} else {
+			r = append(r, fi.Index)
+		}
+	}
+	return r
+}
+
+// FieldsMatching returns every field of t for which pred returns true. It's
+// a general-purpose building block for queries like "every writable,
+// non-primary-key column" that WritableTraversalsByName alone can't
+// express.
+func (m *Mapper) FieldsMatching(t reflect.Type, pred func(FieldInfo) bool) []FieldInfo {
+	tm := m.TypeMap(Deref(t))
+	out := make([]FieldInfo, 0, len(tm))
+	for _, fi := range tm {
+		if pred(fi) {
+			out = append(out, fi)
+		}
+	}
+	return out
+}
+
+// RegisterOption declares name as a recognized db-tag option, e.g. for a
+// custom option like `db:"col,encrypted"` that isn't one of the built-in
+// options (omitempty, readonly, pk and immutable are already built in).
+// Once at least
+// one option has been registered, TypeMapErr rejects any tag option it
+// encounters that isn't built in and hasn't been registered, catching typos
+// that would otherwise be silently collected into fieldInfo.Options and
+// ignored. If validator is non-nil, it's called with the option's value
+// (the empty string if the option carries none) and any error it returns is
+// surfaced from TypeMapErr.
+func (m *Mapper) RegisterOption(name string, validator func(string) error) {
+	m.mutex.Lock()
+	if m.options == nil {
+		m.options = make(map[string]func(string) error)
+	}
+	m.options[name] = validator
+	m.mutex.Unlock()
+}
+
+// TraversalPlan is a precomputed, cacheable description of how to reach a
+// fixed set of named fields on a fixed type. Building a plan walks the
+// Mapper's TypeMap and TraversalsByName once; applying the plan to a value
+// just follows the precomputed []int indexes, so scanning many rows of the
+// same type only pays that walk once instead of once per row. A
+// *TraversalPlan is safe for concurrent read use -- it is never mutated
+// after Plan returns it, and is only ever replaced, not edited in place.
+type TraversalPlan struct {
+	Type    reflect.Type
+	Names   []string
+	Indexes [][]int
+}
+
+// Apply walks v, which must be a struct or a pointer to a struct of the
+// plan's Type, and returns the field values for each of the plan's Names in
+// order. A zero reflect.Value is returned for any name that wasn't found
+// when the plan was built.
+func (p *TraversalPlan) Apply(v reflect.Value) []reflect.Value {
+	v = reflect.Indirect(v)
+	vals := make([]reflect.Value, len(p.Indexes))
+	for i, idx := range p.Indexes {
+		if len(idx) == 0 {
+			continue
+		}
+		vals[i] = FieldByIndexes(v, idx)
+	}
+	return vals
+}
+
+// Plan returns a TraversalPlan for t and names, building and caching it the
+// first time this (type, names) pair is requested. This lets callers that
+// repeatedly scan the same set of names into the same type -- the common
+// case for batch row scanning -- skip the repeated TraversalsByName lookup
+// and reuse the resulting indexes across rows. The plan is invalidated only
+// by discarding the Mapper itself; Plan never mutates a plan already handed
+// out, so holding onto a *TraversalPlan across calls is safe.
+func (m *Mapper) Plan(t reflect.Type, names []string) *TraversalPlan {
+	t = Deref(t)
+	key := strings.Join(names, "\x00")
+
+	m.mutex.Lock()
+	if m.plans == nil {
+		m.plans = make(map[reflect.Type]map[string]*TraversalPlan)
+	}
+	byName := m.plans[t]
+	if byName == nil {
+		byName = make(map[string]*TraversalPlan)
+		m.plans[t] = byName
+	}
+	if p, ok := byName[key]; ok {
+		m.mutex.Unlock()
+		return p
+	}
+	m.mutex.Unlock()
+
+	p := &TraversalPlan{
+		Type:    t,
+		Names:   append([]string(nil), names...),
+		Indexes: m.TraversalsByName(t, names),
+	}
+
+	m.mutex.Lock()
+	byName[key] = p
+	m.mutex.Unlock()
+
+	return p
+}
+
+// RegisterTypeConverter registers fn as the conversion function used when
+// StructToMap or MapToStruct need to convert a value of type from to a value
+// of type to. fn is only ever applied in the from -> to direction; the
+// reverse conversion needs its own registration. This is useful for types like time.Time or
+// sql.NullString that don't have a natural map[string]any representation.
+// Later registrations for the same (from, to) pair take precedence.
+func (m *Mapper) RegisterTypeConverter(from, to reflect.Type, fn func(reflect.Value) (reflect.Value, error)) {
+	m.mutex.Lock()
+	m.converters = append(m.converters, typeConverter{from: from, to: to, fn: fn})
+	m.mutex.Unlock()
+}
+
+// converterTo returns a registered converter that turns a value of type from
+// into a value of type to, if one exists.
+func (m *Mapper) converterTo(from, to reflect.Type) (func(reflect.Value) (reflect.Value, error), bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for i := len(m.converters) - 1; i >= 0; i-- {
+		c := m.converters[i]
+		if c.from == from && c.to == to {
+			return c.fn, true
+		}
+	}
+	return nil, false
+}
+
+// converterFrom returns the most recently registered converter whose from
+// type matches, regardless of its to type. StructToMap uses this: the
+// caller doesn't know (and shouldn't have to declare) what map-value
+// representation a converter like time.Time -> string was registered
+// under.
+func (m *Mapper) converterFrom(from reflect.Type) (func(reflect.Value) (reflect.Value, error), bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for i := len(m.converters) - 1; i >= 0; i-- {
+		c := m.converters[i]
+		if c.from == from {
+			return c.fn, true
+		}
+	}
+	return nil, false
+}
+
+// StructToMap converts v, which must be a struct or a pointer to a struct,
+// into a map[string]any keyed by the same field paths used by FieldByName,
+// honoring the mapper's tag, mapFunc/tagMapFunc and embedded-struct
+// promotion rules. Fields disabled with a "-" name, unexported fields and
+// fields carrying the "omitempty" option whose value is the zero value are
+// left out of the result. Nested, non-embedded struct fields are expanded
+// into their own entries rather than appearing as a single map key.
+func (m *Mapper) StructToMap(v any) (map[string]any, error) {
+	val := reflect.Indirect(reflect.ValueOf(v))
+	if val.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("reflectx: StructToMap expects a struct, got %s", val.Kind())
+	}
+
+	tm := m.TypeMap(val.Type())
+	out := make(map[string]any, len(tm))
+	for _, fi := range tm {
+		if fi.Name == "" || fi.Embedded || tm.hasChildren(fi) {
+			continue
+		}
+
+		fv, err := FieldByIndexesErr(val, fi.Index)
+		if err != nil {
+			var nilEmbedded *NilEmbeddedError
+			if errors.As(err, &nilEmbedded) {
+				// The struct holding this field hasn't been populated;
+				// there's nothing to read, so just leave it out.
+				continue
+			}
+			return nil, fmt.Errorf("reflectx: reading field %q: %w", fi.Path, err)
+		}
+		if fi.OmitEmpty && isZero(fv) {
+			continue
+		}
+
+		if conv, ok := m.converterFrom(fv.Type()); ok {
+			cv, err := conv(fv)
+			if err != nil {
+				return nil, fmt.Errorf("reflectx: converting field %q: %w", fi.Path, err)
+			}
+			out[fi.Path] = cv.Interface()
+			continue
+		}
+
+		out[fi.Path] = fv.Interface()
+	}
+	return out, nil
+}
+
+// MapToStruct copies values from src into dst, which must be a pointer to a
+// struct, matching map keys against the mapper's field paths. Keys that
+// don't match a known path are ignored. If a source value isn't directly
+// assignable to the destination field, MapToStruct looks for a registered
+// type converter before falling back to reflect's own conversion rules.
+func (m *Mapper) MapToStruct(dst any, src map[string]any) error {
+	val := reflect.ValueOf(dst)
+	if val.Kind() != reflect.Ptr || reflect.Indirect(val).Kind() != reflect.Struct {
+		return fmt.Errorf("reflectx: MapToStruct expects a pointer to a struct, got %s", val.Kind())
+	}
+	val = reflect.Indirect(val)
+
+	tm := m.TypeMap(val.Type())
+	for path, raw := range src {
+		fi, ok := tm.GetByPath(path)
+		if !ok {
+			continue
+		}
+
+		fv := FieldByIndexes(val, fi.Index)
+		sv := reflect.ValueOf(raw)
+		if !sv.IsValid() {
+			fv.Set(reflect.Zero(fv.Type()))
+			continue
+		}
+
+		if sv.Type().AssignableTo(fv.Type()) {
+			fv.Set(sv)
+			continue
+		}
+
+		if conv, ok := m.converterTo(sv.Type(), fv.Type()); ok {
+			cv, err := conv(sv)
+			if err != nil {
+				return fmt.Errorf("reflectx: converting field %q: %w", fi.Path, err)
+			}
+			fv.Set(cv)
+			continue
+		}
+
+		if !sv.Type().ConvertibleTo(fv.Type()) {
+			return fmt.Errorf("reflectx: cannot convert field %q from %s to %s", fi.Path, sv.Type(), fv.Type())
+		}
+		fv.Set(sv.Convert(fv.Type()))
+	}
+	return nil
+}
+
+// hasChildren reports whether any field in f has a path nested under fi's
+// path, meaning fi represents a struct that was expanded rather than a leaf
+// value.
+func (f fields) hasChildren(fi fieldInfo) bool {
+	prefix := fi.Path + "."
+	for _, other := range f {
+		if strings.HasPrefix(other.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isZero reports whether v is the zero value for its type.
+func isZero(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
 // FieldByIndexes returns a value for a particular struct traversal.
 func FieldByIndexes(v reflect.Value, indexes []int) reflect.Value {
 	for _, i := range indexes {
@@ -216,6 +835,34 @@ func FieldByIndexesReadOnly(v reflect.Value, indexes []int) reflect.Value {
 	return v
 }
 
+// NilEmbeddedError is returned by FieldByIndexesErr when the traversal
+// reaches a nil embedded pointer and, unlike FieldByIndexes, won't allocate
+// into it to continue.
+type NilEmbeddedError struct {
+	Path []int
+	Type reflect.Type
+}
+
+func (e *NilEmbeddedError) Error() string {
+	return fmt.Sprintf("reflectx: nil embedded pointer of type %s at index path %v", e.Type, e.Path)
+}
+
+// FieldByIndexesErr returns a value for a particular struct traversal, like
+// FieldByIndexesReadOnly, but returns a *NilEmbeddedError instead of
+// panicking when the traversal hits a nil embedded pointer partway through.
+func FieldByIndexesErr(v reflect.Value, indexes []int) (reflect.Value, error) {
+	for i, idx := range indexes {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, &NilEmbeddedError{Path: append([]int(nil), indexes[:i]...), Type: v.Type()}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v, nil
+}
+
 // Deref is Indirect for reflect.Types
 func Deref(t reflect.Type) reflect.Type {
 	if t.Kind() == reflect.Ptr {
@@ -250,9 +897,26 @@ func methodName() string {
 }
 
 type typeQueue struct {
-	t  reflect.Type
-	fi fieldInfo
-	pp string // Parent path
+	t       reflect.Type
+	fi      fieldInfo
+	pp      string // Parent path
+	depth   int
+	visited map[reflect.Type]struct{} // types already seen along this path
+}
+
+// DepthLimitError is wrapped in the error returned by Mapper.TypeMapErr, when the Mapper was
+// configured with WithDepthLimitError, when getMapping's BFS either exceeds
+// MaxDepth or revisits a type already on the current traversal path -- the
+// latter being the signature of a self-referential type such as a linked
+// list or tree node.
+type DepthLimitError struct {
+	Type  reflect.Type
+	Path  string
+	Depth int
+}
+
+func (e *DepthLimitError) Error() string {
+	return fmt.Sprintf("reflectx: %s: not descended into at depth %d at %q (depth limit reached or recursive type)", e.Type, e.Depth, e.Path)
 }
 
 // A copying append that creates a new slice each time.
@@ -265,13 +929,45 @@ func apnd(is []int, i int) []int {
 	return x
 }
 
+// nextQueueEntry builds the typeQueue entry for descending into childType
+// from tq, or returns ok == false if doing so would exceed maxDepth or
+// revisit a type already on this traversal path.
+func nextQueueEntry(tq typeQueue, childType reflect.Type, fi fieldInfo, pp string, maxDepth int) (typeQueue, bool) {
+	if tq.depth+1 > maxDepth {
+		return typeQueue{}, false
+	}
+	if _, seen := tq.visited[childType]; seen {
+		return typeQueue{}, false
+	}
+
+	visited := make(map[reflect.Type]struct{}, len(tq.visited)+1)
+	for t := range tq.visited {
+		visited[t] = struct{}{}
+	}
+	visited[childType] = struct{}{}
+
+	return typeQueue{childType, fi, pp, tq.depth + 1, visited}, true
+}
+
 // getMapping returns a mapping for the t type, using the tagName, mapFunc and
-// tagMapFunc to determine the canonical names of fields.
-func getMapping(t reflect.Type, tagName string, mapFunc, tagMapFunc func(string) string) fields {
+// tagMapFunc to determine the canonical names of fields. maxDepth bounds how
+// far the BFS will descend into nested/embedded structs; it also doubles as
+// a recursion guard -- a type that reappears on its own traversal path (as
+// happens with self-referential types such as a linked list or tree node)
+// stops being descended into even if maxDepth hasn't been reached yet. If
+// the limit fires, the returned *DepthLimitError describes the first place
+// it did; callers that don't care can ignore it and use the (truncated)
+// fields as-is. If options is non-empty, any tag option encountered that's
+// neither a builtinOption nor a key of options is reported as an error,
+// and options whose validator is non-nil have it run against the tag
+// value.
+func getMapping(t reflect.Type, tagName string, mapFunc, tagMapFunc func(string) string, maxDepth int, options map[string]func(string) error) (fields, *DepthLimitError, error) {
 	m := []fieldInfo{}
+	var depthErr *DepthLimitError
 
+	root := Deref(t)
 	queue := []typeQueue{}
-	queue = append(queue, typeQueue{Deref(t), fieldInfo{}, ""})
+	queue = append(queue, typeQueue{root, fieldInfo{}, "", 0, map[reflect.Type]struct{}{root: {}}})
 
 	for len(queue) != 0 {
 		// pop the first item off of the queue
@@ -302,14 +998,31 @@ func getMapping(t reflect.Type, tagName string, mapFunc, tagMapFunc func(string)
 				name = parts[0]
 				for _, opt := range parts[1:] {
 					kv := strings.Split(opt, "=")
+					optName, optVal := kv[0], ""
 					if len(kv) > 1 {
-						fi.Options[kv[0]] = kv[1]
-					} else {
-						fi.Options[kv[0]] = ""
+						optVal = kv[1]
+					}
+					fi.Options[optName] = optVal
+
+					if len(options) > 0 && !builtinOptions[optName] {
+						validator, known := options[optName]
+						if !known {
+							return nil, nil, fmt.Errorf("reflectx: %s.%s: unrecognized tag option %q", t, f.Name, optName)
+						}
+						if validator != nil {
+							if err := validator(optVal); err != nil {
+								return nil, nil, fmt.Errorf("reflectx: %s.%s: invalid option %q: %w", t, f.Name, optName, err)
+							}
+						}
 					}
 				}
 			}
 
+			_, fi.OmitEmpty = fi.Options["omitempty"]
+			_, fi.ReadOnly = fi.Options["readonly"]
+			_, fi.PrimaryKey = fi.Options["pk"]
+			_, fi.Immutable = fi.Options["immutable"]
+
 			// TODO: what to do with this...?
 			if tagMapFunc != nil {
 				tag = tagMapFunc(tag)
@@ -342,12 +1055,20 @@ func getMapping(t reflect.Type, tagName string, mapFunc, tagMapFunc func(string)
 
 				fiq := fi
 				fiq.Index = apnd(tq.fi.Index, fieldPos)
-				queue = append(queue, typeQueue{Deref(f.Type), fiq, pp})
+				if childType, ok := nextQueueEntry(tq, Deref(f.Type), fiq, pp, maxDepth); ok {
+					queue = append(queue, childType)
+				} else if depthErr == nil {
+					depthErr = &DepthLimitError{Type: Deref(f.Type), Path: fiq.Path, Depth: tq.depth + 1}
+				}
 				fi.Embedded = true
 			} else if fi.Zero.Kind() == reflect.Struct {
 				fiq := fi
 				fiq.Index = apnd(tq.fi.Index, fieldPos)
-				queue = append(queue, typeQueue{Deref(f.Type), fiq, fiq.Path})
+				if childType, ok := nextQueueEntry(tq, Deref(f.Type), fiq, fiq.Path, maxDepth); ok {
+					queue = append(queue, childType)
+				} else if depthErr == nil {
+					depthErr = &DepthLimitError{Type: Deref(f.Type), Path: fiq.Path, Depth: tq.depth + 1}
+				}
 			}
 
 			fiq := fi
@@ -355,5 +1076,5 @@ func getMapping(t reflect.Type, tagName string, mapFunc, tagMapFunc func(string)
 			m = append(m, fiq)
 		}
 	}
-	return m
+	return m, depthErr, nil
 }
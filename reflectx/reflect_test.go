@@ -0,0 +1,344 @@
+package reflectx
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// RecurseA and RecurseB are mutually recursive through embedded pointers:
+// A embeds *B, B embeds *A. getMapping's BFS must notice RecurseA
+// reappearing on its own traversal path and stop, rather than looping
+// forever. The embedded types must be exported -- an anonymous field whose
+// type name is unexported is itself unexported and never traversed, which
+// would make this test pass vacuously.
+type RecurseA struct {
+	*RecurseB
+	Name string `db:"name"`
+}
+
+type RecurseB struct {
+	*RecurseA
+	Value int `db:"value"`
+}
+
+func TestTypeMapMutuallyRecursiveTypes(t *testing.T) {
+	done := make(chan fields, 1)
+	go func() {
+		m := NewMapper("db")
+		done <- m.TypeMap(reflect.TypeOf(RecurseA{}))
+	}()
+
+	select {
+	case tm := <-done:
+		if _, ok := tm.GetByPath("name"); !ok {
+			t.Errorf("expected \"name\" in mapping, got %+v", tm)
+		}
+		if _, ok := tm.GetByPath("value"); !ok {
+			t.Errorf("expected \"value\" in mapping, got %+v", tm)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("TypeMap did not return -- BFS likely looped on the recursive type")
+	}
+}
+
+func TestTypeMapMutuallyRecursiveTypesWithDepthLimitError(t *testing.T) {
+	m := NewMapper("db")
+	m.SetOptions(WithDepthLimitError())
+
+	if _, err := m.TypeMapErr(reflect.TypeOf(RecurseA{})); err == nil {
+		t.Fatal("expected a *DepthLimitError, got nil")
+	} else {
+		var depthErr *DepthLimitError
+		if !errors.As(err, &depthErr) {
+			t.Fatalf("expected a *DepthLimitError, got %T: %v", err, err)
+		}
+	}
+}
+
+type depthLeaf struct {
+	Leaf string `db:"leaf"`
+}
+
+type depthMid struct {
+	L2 depthLeaf `db:"l2"`
+}
+
+type depthRoot struct {
+	L1 depthMid `db:"l1"`
+}
+
+func TestTypeMapMaxDepthTruncates(t *testing.T) {
+	m := NewMapper("db")
+	m.SetOptions(WithMaxDepth(1))
+
+	tm := m.TypeMap(reflect.TypeOf(depthRoot{}))
+	if _, ok := tm.GetByPath("l1.l2"); !ok {
+		t.Errorf("expected \"l1.l2\" (the truncated container) in mapping, got %+v", tm)
+	}
+	if _, ok := tm.GetByPath("l1.l2.leaf"); ok {
+		t.Errorf("expected \"l1.l2.leaf\" to be truncated past MaxDepth, but it was mapped")
+	}
+}
+
+func TestTypeMapMaxDepthWithDepthLimitError(t *testing.T) {
+	m := NewMapper("db")
+	m.SetOptions(WithMaxDepth(1), WithDepthLimitError())
+
+	_, err := m.TypeMapErr(reflect.TypeOf(depthRoot{}))
+	var depthErr *DepthLimitError
+	if !errors.As(err, &depthErr) {
+		t.Fatalf("expected a *DepthLimitError, got %T: %v", err, err)
+	}
+}
+
+// InnerEmbed must be exported -- see the comment on RecurseA/RecurseB for
+// why an unexported embedded type name defeats these tests.
+type InnerEmbed struct {
+	Code string `db:"code"`
+}
+
+type OuterWithNilEmbed struct {
+	*InnerEmbed
+	ID int `db:"id"`
+}
+
+func TestStructToMapNilEmbeddedPointer(t *testing.T) {
+	m := NewMapper("db")
+
+	out, err := m.StructToMap(OuterWithNilEmbed{ID: 1})
+	if err != nil {
+		t.Fatalf("StructToMap returned an error for an unset embedded pointer: %v", err)
+	}
+	if out["id"] != 1 {
+		t.Errorf("expected id=1, got %+v", out)
+	}
+	if _, ok := out["code"]; ok {
+		t.Errorf("expected \"code\" to be omitted since its embedding struct is nil, got %+v", out)
+	}
+}
+
+func TestFieldByNameStrictNilEmbeddedPointer(t *testing.T) {
+	m := NewMapper("db")
+	m.SetOptions(WithStrictFieldTraversal())
+
+	target := &OuterWithNilEmbed{ID: 1}
+	v := reflect.ValueOf(target).Elem()
+	fv := m.FieldByName(v, "code")
+	if fv.IsValid() {
+		t.Errorf("expected a zero Value for a field behind a nil embedded pointer in strict mode, got %+v", fv)
+	}
+
+	// Strict mode must not allocate into the nil embedded pointer as a
+	// side effect of the read.
+	if target.InnerEmbed != nil {
+		t.Errorf("expected InnerEmbed to remain nil, got %+v", target.InnerEmbed)
+	}
+}
+
+type withTime struct {
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func TestStructToMapRegisteredConverter(t *testing.T) {
+	m := NewMapper("db")
+	m.RegisterTypeConverter(reflect.TypeOf(time.Time{}), reflect.TypeOf(""), func(v reflect.Value) (reflect.Value, error) {
+		return reflect.ValueOf(v.Interface().(time.Time).Format(time.RFC3339)), nil
+	})
+
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	out, err := m.StructToMap(withTime{CreatedAt: when})
+	if err != nil {
+		t.Fatalf("StructToMap: %v", err)
+	}
+
+	got, ok := out["created_at"].(string)
+	if !ok {
+		t.Fatalf("expected created_at to be converted to a string, got %T: %v", out["created_at"], out["created_at"])
+	}
+	if want := when.Format(time.RFC3339); got != want {
+		t.Errorf("created_at = %q, want %q", got, want)
+	}
+}
+
+type optsStruct struct {
+	ID   int    `db:"id,pk,readonly"`
+	Name string `db:"name"`
+}
+
+func TestRegisterOptionRejectsUnrecognized(t *testing.T) {
+	m := NewMapper("db")
+	m.RegisterOption("encrypted", nil)
+
+	type typoStruct struct {
+		Name string `db:"name,encryptd"` // typo: "encryptd" instead of "encrypted"
+	}
+
+	if _, err := m.TypeMapErr(reflect.TypeOf(typoStruct{})); err == nil {
+		t.Fatal("expected an error for an unrecognized tag option, got nil")
+	}
+}
+
+func TestWritableTraversalsByNameSkipsReadOnly(t *testing.T) {
+	m := NewMapper("db")
+
+	trav := m.WritableTraversalsByName(reflect.TypeOf(optsStruct{}), []string{"id", "name"})
+	if len(trav[0]) != 0 {
+		t.Errorf("expected \"id\" (readonly) to come back empty, got %v", trav[0])
+	}
+	if len(trav[1]) == 0 {
+		t.Errorf("expected \"name\" to be writable, got empty traversal")
+	}
+}
+
+type planStruct struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+func TestPlanApplyRoundTrip(t *testing.T) {
+	m := NewMapper("db")
+
+	p := m.Plan(reflect.TypeOf(planStruct{}), []string{"id", "name", "missing"})
+	row := planStruct{ID: 7, Name: "alice"}
+	vals := p.Apply(reflect.ValueOf(row))
+
+	if len(vals) != 3 {
+		t.Fatalf("expected 3 values, got %d", len(vals))
+	}
+	if got := vals[0].Interface(); got != 7 {
+		t.Errorf("id = %v, want 7", got)
+	}
+	if got := vals[1].Interface(); got != "alice" {
+		t.Errorf("name = %v, want %q", got, "alice")
+	}
+	if vals[2].IsValid() {
+		t.Errorf("expected a zero Value for the unknown name, got %+v", vals[2])
+	}
+}
+
+func TestPlanIsCachedPerTypeAndNames(t *testing.T) {
+	m := NewMapper("db")
+	names := []string{"id", "name"}
+
+	first := m.Plan(reflect.TypeOf(planStruct{}), names)
+	second := m.Plan(reflect.TypeOf(planStruct{}), names)
+	if first != second {
+		t.Error("expected Plan to return the same cached *TraversalPlan for the same type and names")
+	}
+
+	third := m.Plan(reflect.TypeOf(planStruct{}), []string{"name", "id"})
+	if first == third {
+		t.Error("expected Plan to return a distinct plan for a different name order")
+	}
+}
+
+type ConfLeafA struct {
+	Name string `db:"name"`
+}
+
+type ConfLeafB struct {
+	Name string `db:"name"`
+}
+
+// ConfMid embeds ConfLeafB one level deeper than ConfOuterDepth's direct
+// ConfLeafA embed, giving ConfOuterDepth.ConfMid.Name a greater embed depth
+// than ConfOuterDepth.ConfLeafA.Name despite both mapping to "name".
+type ConfMid struct {
+	ConfLeafB
+}
+
+// ConfOuterTie embeds two structs exposing the same field name at the same
+// depth -- the ambiguous case each ConflictPolicy must resolve differently.
+type ConfOuterTie struct {
+	ConfLeafA
+	ConfLeafB
+}
+
+type ConfOuterDepth struct {
+	ConfLeafA
+	ConfMid
+}
+
+func TestConflictFirstWinsIsDefault(t *testing.T) {
+	m := NewMapper("db")
+
+	tm := m.TypeMap(reflect.TypeOf(ConfOuterTie{}))
+	fi, ok := tm.GetByPath("name")
+	if !ok {
+		t.Fatal("expected \"name\" in mapping")
+	}
+	if fi.Index[0] != 0 {
+		t.Errorf("expected ConflictFirstWins to keep the first-declared (ConfLeafA) field, got index %v", fi.Index)
+	}
+}
+
+func TestConflictErrorRejectsAmbiguousName(t *testing.T) {
+	m := NewMapper("db")
+	m.SetConflictPolicy(ConflictError)
+
+	if _, err := m.TypeMapErr(reflect.TypeOf(ConfOuterTie{})); err == nil {
+		t.Fatal("expected an error for an ambiguous field name, got nil")
+	}
+}
+
+func TestConflictShallowestWinsPicksShallower(t *testing.T) {
+	m := NewMapper("db")
+	m.SetConflictPolicy(ConflictShallowestWins)
+
+	tm, err := m.TypeMapErr(reflect.TypeOf(ConfOuterDepth{}))
+	if err != nil {
+		t.Fatalf("TypeMapErr: %v", err)
+	}
+	fi, ok := tm.GetByPath("name")
+	if !ok {
+		t.Fatal("expected \"name\" in mapping")
+	}
+	if len(fi.Index) != 2 {
+		t.Errorf("expected the shallower ConfLeafA.Name to win, got index %v", fi.Index)
+	}
+}
+
+func TestConflictShallowestWinsErrorsOnTie(t *testing.T) {
+	m := NewMapper("db")
+	m.SetConflictPolicy(ConflictShallowestWins)
+
+	if _, err := m.TypeMapErr(reflect.TypeOf(ConfOuterTie{})); err == nil {
+		t.Fatal("expected an error for fields tied at the same embed depth, got nil")
+	}
+}
+
+func TestConflictCustomPicksResolverWinner(t *testing.T) {
+	m := NewMapper("db")
+	m.SetConflictPolicy(ConflictCustom(func(group []FieldInfo) (FieldInfo, error) {
+		return group[len(group)-1], nil
+	}))
+
+	tm, err := m.TypeMapErr(reflect.TypeOf(ConfOuterTie{}))
+	if err != nil {
+		t.Fatalf("TypeMapErr: %v", err)
+	}
+	fi, ok := tm.GetByPath("name")
+	if !ok {
+		t.Fatal("expected \"name\" in mapping")
+	}
+	if fi.Index[0] != 1 {
+		t.Errorf("expected the custom resolver's chosen (ConfLeafB) field to win, got index %v", fi.Index)
+	}
+}
+
+func TestConflictsReportsCompetingFields(t *testing.T) {
+	m := NewMapper("db")
+	m.SetConflictPolicy(ConflictFirstWins)
+
+	m.TypeMap(reflect.TypeOf(ConfOuterTie{}))
+	conflicts := m.Conflicts(reflect.TypeOf(ConfOuterTie{}))
+	group, ok := conflicts["name"]
+	if !ok {
+		t.Fatal("expected Conflicts to report the \"name\" ambiguity")
+	}
+	if len(group) != 2 {
+		t.Errorf("expected 2 competing fields, got %d", len(group))
+	}
+}